@@ -39,7 +39,7 @@ func resolveName(name string) (path string, ok bool) {
 			log.Fatal(err)
 		}
 
-		var fullName string
+		var match *buildInfo
 		for _, b := range builds {
 			if !strings.HasPrefix(b.commitHash, nameParts[0]) {
 				continue
@@ -52,13 +52,23 @@ func resolveName(name string) (path string, ok bool) {
 			}
 
 			// We found a match.
-			if fullName != "" {
+			if match != nil {
 				log.Fatalf("ambiguous name `%s`", name)
 			}
-			fullName = b.fullName()
+			match = b
 		}
-		if fullName != "" {
-			return filepath.Join(*verDir, fullName), true
+		if match != nil {
+			if match.remote != nil {
+				// This build only exists in a remote
+				// store so far; fetch it into *verDir
+				// so future resolves hit the local copy.
+				path, err := fetchRemote(match.remote, match.fullName())
+				if err != nil {
+					log.Fatalf("fetching `%s` from remote: %v", match.fullName(), err)
+				}
+				return path, true
+			}
+			return filepath.Join(*verDir, match.fullName()), true
 		}
 	}
 
@@ -71,6 +81,11 @@ type buildInfo struct {
 	deltaHash  string
 	names      []string
 	commit     *commit
+
+	// remote is set if this build has not yet been fetched into
+	// *verDir: it is the store it was listed from, so resolveName
+	// can fetch it into the local cache on first use.
+	remote BuildStore
 }
 
 func (i buildInfo) fullName() string {
@@ -95,8 +110,39 @@ const (
 	listCommit
 )
 
+// listBuilds lists the builds in *verDir along with any builds
+// available in a configured remote BuildStore that have not yet been
+// fetched locally; remote-only entries have buildInfo.remote set.
 func listBuilds(flags listFlags) ([]*buildInfo, error) {
-	files, err := ioutil.ReadDir(*verDir)
+	builds, err := (localStore{dir: *verDir}).List(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	have := make(map[string]bool)
+	for _, b := range builds {
+		have[b.fullName()] = true
+	}
+	for _, store := range remoteStores() {
+		remoteBuilds, err := store.List(flags)
+		if err != nil {
+			log.Printf("gover: listing remote builds: %v", err)
+			continue
+		}
+		for _, b := range remoteBuilds {
+			if !have[b.fullName()] {
+				builds = append(builds, b)
+				have[b.fullName()] = true
+			}
+		}
+	}
+	return builds, nil
+}
+
+// listLocalBuilds lists the builds saved in dir, which is either
+// *verDir or, when used by localStore, any BuildStore rooted at dir.
+func listLocalBuilds(dir string, flags listFlags) ([]*buildInfo, error) {
+	files, err := ioutil.ReadDir(dir)
 	if os.IsNotExist(err) {
 		return nil, nil
 	} else if err != nil {
@@ -114,7 +160,7 @@ func listBuilds(flags listFlags) ([]*buildInfo, error) {
 			continue
 		}
 		nameParts := strings.SplitN(file.Name(), "+", 2)
-		info := &buildInfo{path: filepath.Join(*verDir, file.Name()), commitHash: nameParts[0]}
+		info := &buildInfo{path: filepath.Join(dir, file.Name()), commitHash: nameParts[0]}
 		if len(nameParts) > 1 {
 			info.deltaHash = nameParts[1]
 		}
@@ -125,7 +171,7 @@ func listBuilds(flags listFlags) ([]*buildInfo, error) {
 		}
 
 		if flags&listCommit != 0 {
-			commit, err := ioutil.ReadFile(filepath.Join(*verDir, file.Name(), "commit"))
+			commit, err := ioutil.ReadFile(filepath.Join(dir, file.Name(), "commit"))
 			if err != nil {
 				log.Fatal(err)
 			} else {
@@ -138,7 +184,7 @@ func listBuilds(flags listFlags) ([]*buildInfo, error) {
 	if flags&listNames != 0 {
 		for _, file := range files {
 			if file.Mode()&os.ModeType == os.ModeSymlink {
-				base, err := os.Readlink(filepath.Join(*verDir, file.Name()))
+				base, err := os.Readlink(filepath.Join(dir, file.Name()))
 				if err != nil {
 					continue
 				}
@@ -0,0 +1,378 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BuildStore is a source of saved Go toolchain builds, keyed by
+// their full name (a commit hash, optionally plus a delta hash; see
+// buildInfo.fullName). The local *verDir is itself a BuildStore;
+// other implementations let a team share one build cache instead of
+// every developer maintaining their own.
+type BuildStore interface {
+	// List returns the builds available in this store.
+	List(flags listFlags) ([]*buildInfo, error)
+	// Open returns the build tree for fullName.
+	Open(fullName string) (fs.FS, error)
+	// Put saves dir as the build tree for fullName.
+	Put(fullName string, dir fs.FS) error
+}
+
+// localStore is the BuildStore backed by the local *verDir.
+type localStore struct{ dir string }
+
+func (s localStore) List(flags listFlags) ([]*buildInfo, error) {
+	return listLocalBuilds(s.dir, flags)
+}
+
+func (s localStore) Open(fullName string) (fs.FS, error) {
+	return os.DirFS(filepath.Join(s.dir, fullName)), nil
+}
+
+func (s localStore) Put(fullName string, dir fs.FS) error {
+	return copyFS(filepath.Join(s.dir, fullName), dir)
+}
+
+// remoteStore is a BuildStore backed by an HTTP/S3-style object
+// store: GET $endpoint/$prefix/list returns a newline-separated list
+// of full names, GET $endpoint/$prefix/$fullName/manifest returns the
+// build tree's file manifest (see manifest), and GET
+// $endpoint/$prefix/$fullName/... fetches one file out of a build
+// tree. It is configured entirely from the environment so a team can
+// point every checkout at a shared cache without per-repo
+// configuration.
+type remoteStore struct {
+	endpoint string // e.g. GOVER_REMOTE=https://example.com/gover
+	prefix   string // e.g. GOVER_REMOTE_PREFIX=linux-amd64
+	token    string // e.g. GOVER_REMOTE_TOKEN, sent as a bearer token
+	client   *http.Client
+}
+
+// remoteStores returns the remote stores configured via the
+// GOVER_REMOTE* environment variables, if any.
+func remoteStores() []BuildStore {
+	endpoint := os.Getenv("GOVER_REMOTE")
+	if endpoint == "" {
+		return nil
+	}
+	return []BuildStore{remoteStore{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		prefix:   os.Getenv("GOVER_REMOTE_PREFIX"),
+		token:    os.Getenv("GOVER_REMOTE_TOKEN"),
+		client:   http.DefaultClient,
+	}}
+}
+
+func (s remoteStore) url(parts ...string) string {
+	u := s.endpoint
+	if s.prefix != "" {
+		u += "/" + s.prefix
+	}
+	for _, p := range parts {
+		u += "/" + p
+	}
+	return u
+}
+
+func (s remoteStore) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func (s remoteStore) List(flags listFlags) ([]*buildInfo, error) {
+	resp, err := s.get(s.url("list"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A remote store has no symlinked names or commit objects to
+	// read, so listNames and listCommit are ignored: they only
+	// apply to listLocalBuilds.
+	var builds []*buildInfo
+	for _, fullName := range strings.Fields(string(body)) {
+		nameParts := strings.SplitN(fullName, "+", 2)
+		info := &buildInfo{commitHash: nameParts[0], remote: s}
+		if len(nameParts) > 1 {
+			info.deltaHash = nameParts[1]
+		}
+		builds = append(builds, info)
+	}
+	return builds, nil
+}
+
+func (s remoteStore) Open(fullName string) (fs.FS, error) {
+	return &remoteFS{store: s, fullName: fullName}, nil
+}
+
+func (s remoteStore) Put(fullName string, dir fs.FS) error {
+	return fmt.Errorf("gover: publishing to a remote build store is not supported")
+}
+
+// manifestEntry describes one file in a build tree, as listed by a
+// remoteStore's manifest endpoint.
+type manifestEntry struct {
+	path string // slash-separated, relative to the build tree's root
+	mode fs.FileMode
+}
+
+// manifest fetches and parses the file manifest for fullName: one
+// line per file, "<octal mode> <path>". The mode lets copyFS preserve
+// a toolchain binary's executable bit when it materializes the build
+// locally.
+func (s remoteStore) manifest(fullName string) ([]manifestEntry, error) {
+	resp, err := s.get(s.url(fullName, "manifest"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []manifestEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("gover: malformed manifest line %q", line)
+		}
+		mode, err := strconv.ParseUint(fields[0], 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("gover: malformed manifest mode %q in %q", fields[0], line)
+		}
+		// A manifest comes from a remote, possibly attacker- or
+		// MITM-controlled endpoint (GOVER_REMOTE may be a plain
+		// HTTP URL); fs.ValidPath rejects anything — an absolute
+		// path, a ".." element, and so on — that could let a path
+		// escape the directory copyFS later materializes it under.
+		if !fs.ValidPath(fields[1]) {
+			return nil, fmt.Errorf("gover: invalid manifest path %q in %q", fields[1], line)
+		}
+		entries = append(entries, manifestEntry{path: fields[1], mode: fs.FileMode(mode)})
+	}
+	return entries, nil
+}
+
+// remoteFS implements fs.FS, fs.ReadDirFS, and fs.StatFS for a single
+// build tree in a remoteStore, so fs.WalkDir (used by copyFS) can
+// enumerate it. It fetches the tree's manifest once, lazily, then
+// fetches individual files, one at a time, as copyFS copies them.
+type remoteFS struct {
+	store    remoteStore
+	fullName string
+
+	entries []manifestEntry // lazily populated by load
+}
+
+func (r *remoteFS) load() ([]manifestEntry, error) {
+	if r.entries == nil {
+		entries, err := r.store.manifest(r.fullName)
+		if err != nil {
+			return nil, err
+		}
+		r.entries = entries
+	}
+	return r.entries, nil
+}
+
+func (r *remoteFS) find(name string) (manifestEntry, error) {
+	entries, err := r.load()
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	for _, e := range entries {
+		if e.path == name {
+			return e, nil
+		}
+	}
+	return manifestEntry{}, fs.ErrNotExist
+}
+
+func (r *remoteFS) Open(name string) (fs.File, error) {
+	e, err := r.find(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	resp, err := r.store.get(r.store.url(r.fullName, name))
+	if err != nil {
+		return nil, err
+	}
+	return &remoteFile{ReadCloser: resp.Body, name: filepath.Base(name), mode: e.mode}, nil
+}
+
+func (r *remoteFS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return remoteDirInfo("."), nil
+	}
+	e, err := r.find(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return remoteFileInfo{name: filepath.Base(name), mode: e.mode}, nil
+}
+
+// ReadDir lists the immediate children of name (or the tree's root,
+// for "."), synthesized from the manifest: any manifest path with a
+// "/" after the name prefix names a subdirectory, and any other
+// manifest path under the prefix names a file.
+func (r *remoteFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	seen := make(map[string]bool)
+	var out []fs.DirEntry
+	for _, e := range entries {
+		rest := strings.TrimPrefix(e.path, prefix)
+		if rest == e.path {
+			continue // not under this directory
+		}
+		child := strings.SplitN(rest, "/", 2)
+		if seen[child[0]] {
+			continue
+		}
+		seen[child[0]] = true
+		if len(child) > 1 {
+			out = append(out, remoteDirInfo(child[0]))
+		} else {
+			out = append(out, remoteFileInfo{name: child[0], mode: e.mode})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+type remoteFile struct {
+	io.ReadCloser
+	name string
+	mode fs.FileMode
+}
+
+func (f *remoteFile) Stat() (fs.FileInfo, error) {
+	return remoteFileInfo{name: f.name, mode: f.mode}, nil
+}
+
+// remoteFileInfo and remoteDirInfo implement fs.FileInfo and
+// fs.DirEntry for the synthetic entries ReadDir and Stat hand back;
+// neither a remote build's files nor its directories carry a useful
+// mod time, so ModTime is always zero.
+type remoteFileInfo struct {
+	name string
+	mode fs.FileMode
+}
+
+func (i remoteFileInfo) Name() string               { return i.name }
+func (i remoteFileInfo) Size() int64                { return 0 }
+func (i remoteFileInfo) Mode() fs.FileMode          { return i.mode }
+func (i remoteFileInfo) ModTime() time.Time         { return time.Time{} }
+func (i remoteFileInfo) IsDir() bool                { return false }
+func (i remoteFileInfo) Sys() interface{}           { return nil }
+func (i remoteFileInfo) Type() fs.FileMode          { return i.mode.Type() }
+func (i remoteFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+type remoteDirInfo string
+
+func (i remoteDirInfo) Name() string               { return string(i) }
+func (i remoteDirInfo) Size() int64                { return 0 }
+func (i remoteDirInfo) Mode() fs.FileMode          { return fs.ModeDir | 0755 }
+func (i remoteDirInfo) ModTime() time.Time         { return time.Time{} }
+func (i remoteDirInfo) IsDir() bool                { return true }
+func (i remoteDirInfo) Sys() interface{}           { return nil }
+func (i remoteDirInfo) Type() fs.FileMode          { return fs.ModeDir }
+func (i remoteDirInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// copyFS copies every regular file in src into a fresh directory
+// dstDir, preserving each file's permissions (in particular the
+// executable bit on toolchain binaries like cmd/compile and cmd/link)
+// so the result is usable directly as a GOROOT. It is used both to
+// materialize a remote build tree locally the first time it is
+// resolved and, via localStore.Put, to save a newly built tree.
+func copyFS(dstDir string, src fs.FS) error {
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return err
+	}
+	return fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		in, err := src.Open(name)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		dst := filepath.Join(dstDir, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
+
+// fetchRemote materializes the named build from store into *verDir,
+// for use the first time resolveName finds it only in a remote.
+func fetchRemote(store BuildStore, fullName string) (string, error) {
+	tree, err := store.Open(fullName)
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(*verDir, fullName)
+	if err := copyFS(dst, tree); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// TestCopyFSPreservesMode confirms copyFS carries over a file's
+// permissions (in particular the executable bit), since that is what
+// lets a materialized build tree be used directly as a GOROOT.
+func TestCopyFSPreservesMode(t *testing.T) {
+	src := fstest.MapFS{
+		"bin/go": &fstest.MapFile{Data: []byte("#!/bin/true\n"), Mode: 0755},
+		"README": &fstest.MapFile{Data: []byte("hi\n"), Mode: 0644},
+	}
+
+	dst := t.TempDir()
+	if err := copyFS(dst, src); err != nil {
+		t.Fatalf("copyFS: %v", err)
+	}
+
+	for name, want := range map[string]os.FileMode{"bin/go": 0755, "README": 0644} {
+		info, err := os.Stat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: mode = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// TestManifestRejectsPathTraversal confirms that a manifest entry
+// whose path escapes the build tree (as a compromised or
+// MITM'd remote store might send) is rejected before it can ever
+// reach remoteFS.find/ReadDir/Open or copyFS.
+func TestManifestRejectsPathTraversal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0755 ../../../../etc/cron.d/evil\n"))
+	}))
+	defer srv.Close()
+
+	s := remoteStore{endpoint: srv.URL, client: http.DefaultClient}
+	if _, err := s.manifest("deadbeef"); err == nil {
+		t.Errorf("manifest with a path-traversal entry succeeded; want an error")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "fmt"
+
+// modelClasses groups the indexes of models into equivalence
+// classes, where i and j are in the same class if neither has a
+// counterexample against the other (so the sweep could not tell them
+// apart). reaches reports, for two model indexes, whether the first
+// is stronger than or equal to the second (i.e. whether
+// counterexamples[i][j] is empty).
+func modelClasses(reaches func(i, j int) bool) [][]int {
+	n := len(models)
+	seen := make([]bool, n)
+	var classes [][]int
+	for i := 0; i < n; i++ {
+		if seen[i] {
+			continue
+		}
+		class := []int{i}
+		seen[i] = true
+		for j := i + 1; j < n; j++ {
+			if !seen[j] && reaches(i, j) && reaches(j, i) {
+				class = append(class, j)
+				seen[j] = true
+			}
+		}
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+// classEdges computes the edges of the strength order on classes
+// (classes[u] -> classes[v] when every model in u is stronger than
+// or equal to every model in v), then returns its transitive
+// reduction: for every edge u -> v, the edge is dropped if there is
+// some other class w with edges u -> w and w -> v.
+func classEdges(classes [][]int, reaches func(i, j int) bool) [][2]int {
+	n := len(classes)
+	classReaches := func(u, v int) bool {
+		return reaches(classes[u][0], classes[v][0])
+	}
+
+	full := make(map[[2]int]bool)
+	for u := 0; u < n; u++ {
+		for v := 0; v < n; v++ {
+			if u != v && classReaches(u, v) {
+				full[[2]int{u, v}] = true
+			}
+		}
+	}
+
+	var edges [][2]int
+	for uv := range full {
+		u, v := uv[0], uv[1]
+		reduced := false
+		for w := 0; w < n; w++ {
+			if w == u || w == v {
+				continue
+			}
+			if full[[2]int{u, w}] && full[[2]int{w, v}] {
+				reduced = true
+				break
+			}
+		}
+		if !reduced {
+			edges = append(edges, [2]int{u, v})
+		}
+	}
+	return edges
+}
+
+// classLabel joins the names of every model in a class into a single
+// dot node label.
+func classLabel(class []int) string {
+	label := ""
+	for i, idx := range class {
+		if i > 0 {
+			label += "\\n= "
+		}
+		label += fmt.Sprint(models[idx])
+	}
+	return label
+}
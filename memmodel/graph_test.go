@@ -0,0 +1,52 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// reachTable describes a synthetic strength order over len(models)
+// model indexes: 0 and 1 are equally strong, 0 and 1 are each
+// stronger than 2, which is in turn stronger than 3; 4 is unrelated
+// to everything. It also includes a redundant direct edge from 0 and
+// 1 to 3, to exercise classEdges' transitive reduction.
+var reachTable = map[[2]int]bool{
+	{0, 1}: true, {1, 0}: true,
+	{0, 2}: true, {1, 2}: true,
+	{2, 3}: true,
+	{0, 3}: true, {1, 3}: true,
+}
+
+func reaches(i, j int) bool { return reachTable[[2]int{i, j}] }
+
+// TestModelClasses confirms that mutually-reaching model indexes are
+// grouped into a single equivalence class, and that every other
+// index gets its own singleton class.
+func TestModelClasses(t *testing.T) {
+	classes := modelClasses(reaches)
+	want := [][]int{{0, 1}, {2}, {3}, {4}}
+	if !reflect.DeepEqual(classes, want) {
+		t.Errorf("modelClasses(reaches) = %v, want %v", classes, want)
+	}
+}
+
+// TestClassEdges confirms that classEdges drops an edge u -> v when
+// some other class w has edges u -> w and w -> v, even though
+// reaches itself reports u -> v directly.
+func TestClassEdges(t *testing.T) {
+	classes := modelClasses(reaches)
+	edges := classEdges(classes, reaches)
+
+	want := map[[2]int]bool{{0, 1}: true, {1, 2}: true}
+	got := make(map[[2]int]bool, len(edges))
+	for _, e := range edges {
+		got[e] = true
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("classEdges(classes, reaches) = %v, want the edges %v (and no direct class-0-to-class-2 edge, since it's transitively implied)", edges, want)
+	}
+}
@@ -7,12 +7,13 @@
 // It generates a large number of "litmus test" programs consisting of
 // reads and writes of variables on multiple threads. For each
 // program, it determines all permissible outcomes under different
-// memory models (currently SC, TSO, and TSO with memory barriers
-// after stores) and determines which memory models are weaker or
-// stronger than which others. It produces a dot graph of the partial
-// order of memory model strength and, for every pair of models A and
-// B where A is weaker than B, it gives an example program where A
-// permits outcomes that B excludes.
+// memory models (currently SC, TSO, TSO with memory barriers after
+// stores, an ARMv8-style release-consistency model, and a
+// POWER-style multi-copy-atomic weak model) and determines which
+// memory models are weaker or stronger than which others. It
+// produces a dot graph of the partial order of memory model strength
+// and, for every pair of models A and B where A is weaker than B, it
+// gives an example program where A permits outcomes that B excludes.
 package main
 
 import (
@@ -27,10 +28,11 @@ type Model interface {
 	Eval(p *Prog, outcomes *OutcomeSet)
 }
 
-var models = []Model{SCModel{}, TSOModel{}, TSOModel{StoreMFence: true}}
+var models = []Model{SCModel{}, TSOModel{}, TSOModel{StoreMFence: true}, RCModel{}, PowerModel{}}
 
 func main() {
 	flagOut := flag.String("o", "", "continuously write model graph to `output` dot file")
+	flagMinimize := flag.Bool("minimize", true, "minimize counterexamples before printing")
 	flag.Parse()
 	if flag.NArg() > 0 {
 		flag.Usage()
@@ -72,10 +74,12 @@ func main() {
 					// Model i permits outcomes
 					// that model j does not. (i
 					// is weaker than j.)
+					// Keep the first counterexample we
+					// find; minimizeCounterexample
+					// shrinks it once the sweep is
+					// done.
 					counterexamples[i][j] = p
 				}
-				// TODO: Prefer smaller
-				// counterexamples.
 			}
 		}
 
@@ -94,6 +98,17 @@ func main() {
 	}
 	fmt.Fprintf(os.Stderr, "\r%d progs\n", n)
 
+	if *flagMinimize {
+		for i := range counterexamples {
+			for j, p := range counterexamples[i] {
+				if i == j || p.Threads[0].Ops[0].Type == OpExit {
+					continue
+				}
+				counterexamples[i][j] = minimizeCounterexample(p, models[i], models[j])
+			}
+		}
+	}
+
 	f := os.Stdout
 	if *flagOut != "" {
 		var err error
@@ -108,38 +123,45 @@ func main() {
 }
 
 func writeModelGraph(w io.Writer, counterexamples [][]Prog) {
-	// TODO: Find maximal cliques and compact them in to single
-	// nodes. That should turn the non-strict partial order into a
-	// strict partial order and declutter things. We could then
-	// run a transitive reduction.
+	reaches := func(i, j int) bool {
+		return counterexamples[i][j].Threads[0].Ops[0].Type == OpExit
+	}
+
+	// Collapse mutually-equivalent models (models with no
+	// counterexample against each other) into single nodes, then
+	// compute the transitive reduction of the strength order on
+	// the resulting classes. This turns the non-strict partial
+	// order into a Hasse diagram.
+	classes := modelClasses(reaches)
+	edges := classEdges(classes, reaches)
 
 	fmt.Fprintln(w, "digraph memmodel {")
 	fmt.Fprintln(w, "label=\"A -> B means A is stronger than or equal to B\";")
 
-	// Add all nodes. This is necessary if some model isn't
+	// Add all nodes. This is necessary if some class isn't
 	// comparable to anything.
-	for _, model := range models {
-		fmt.Fprintf(w, "%q;\n", model)
+	for _, class := range classes {
+		fmt.Fprintf(w, "%q;\n", classLabel(class))
+	}
+
+	// Add the reduced edges.
+	for _, uv := range edges {
+		fmt.Fprintf(w, "%q -> %q;\n", classLabel(classes[uv[0]]), classLabel(classes[uv[1]]))
 	}
 
-	// Add edges.
+	// Print the counterexamples (the reasons edges are missing)
+	// as comments, one per pair of distinguishable models.
 	for i := range counterexamples {
 		for j, p := range counterexamples[i] {
-			if i == j {
+			if i == j || reaches(i, j) {
 				continue
 			}
-			if p.Threads[0].Ops[0].Type == OpExit {
-				// No counterexample. Model i is
-				// stronger than or equal to model j.
-				fmt.Fprintf(w, "%q -> %q;\n", models[i], models[j])
-			} else {
-				// Print the counter example. Model i
-				// is weaker than model j.
-				fmt.Fprintf(w, "# %q is weaker than %q;\n", models[i], models[j])
-				fmt.Fprintln(w, "# "+strings.Replace(p.String(), "\n", "\n# ", -1))
-				// TODO: Print an example of why.
-			}
+			fmt.Fprintf(w, "# %q is weaker than %q;\n", models[i], models[j])
+			fmt.Fprintln(w, "# "+strings.Replace(p.String(), "\n", "\n# ", -1))
+			fmt.Fprintf(w, "# witness outcome: %s permits %s, which %s forbids;\n",
+				models[i], witnessOutcome(p, models[i], models[j]), models[j])
 		}
 	}
+
 	fmt.Fprintln(w, "}")
-}
\ No newline at end of file
+}
@@ -0,0 +1,161 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// minimizeCounterexample shrinks p to a locally-minimal program that
+// still demonstrates that model weak permits an outcome that model
+// strong forbids. It uses a ddmin-style loop: first it tries
+// dropping whole threads, then individual ops within each thread,
+// then it tries replacing each store's value with a smaller integer
+// and each address with a lower-numbered one. Any change for which
+// evalCache.differ still reports true is kept; the loop
+// repeats until no single change shrinks the program any further.
+func minimizeCounterexample(p Prog, weak, strong Model) Prog {
+	cache := newEvalCache(weak, strong)
+	if !cache.differ(p) {
+		// Shouldn't happen: the caller only minimizes confirmed
+		// counterexamples.
+		return p
+	}
+
+	progress := true
+	for progress {
+		progress = false
+
+		// Try dropping each thread entirely.
+		for i := 0; i < p.numThreads(); i++ {
+			cand := removeThread(p, i)
+			if cache.differ(cand) {
+				p, progress = cand, true
+				i--
+			}
+		}
+
+		// Try dropping each op.
+		for ti := 0; ti < p.numThreads(); ti++ {
+			for oi := 0; oi < p.Threads[ti].numOps(); oi++ {
+				cand := removeOp(p, ti, oi)
+				if cache.differ(cand) {
+					p, progress = cand, true
+					oi--
+				}
+			}
+		}
+
+		// Try shrinking store values and load and store addresses.
+		for ti := 0; ti < p.numThreads(); ti++ {
+			for oi := 0; oi < p.Threads[ti].numOps(); oi++ {
+				op := p.Threads[ti].Ops[oi]
+				if !op.Type.isLoad() && !op.Type.isStore() {
+					continue
+				}
+				if smaller, ok := shrinkInt(op.Value); ok {
+					if tryOpEdit(&p, ti, oi, func(o *Op) { o.Value = smaller }, cache) {
+						progress = true
+					}
+				}
+				if lower, ok := shrinkInt(op.Addr); ok {
+					if tryOpEdit(&p, ti, oi, func(o *Op) { o.Addr = lower }, cache) {
+						progress = true
+					}
+				}
+			}
+		}
+	}
+
+	return p
+}
+
+// removeThread returns a copy of p with thread i deleted, shifting
+// later threads down to keep active threads packed at the front (the
+// convention numThreads relies on).
+func removeThread(p Prog, i int) Prog {
+	n := p.numThreads()
+	for j := i; j < n-1; j++ {
+		p.Threads[j] = p.Threads[j+1]
+	}
+	p.Threads[n-1] = Thread{}
+	return p
+}
+
+// removeOp returns a copy of p with op oi of thread ti deleted,
+// shifting later ops down to keep them packed at the front of
+// Thread.Ops. Removing a thread's only op removes the thread.
+func removeOp(p Prog, ti, oi int) Prog {
+	n := p.Threads[ti].numOps()
+	if n == 1 {
+		return removeThread(p, ti)
+	}
+	for j := oi; j < n-1; j++ {
+		p.Threads[ti].Ops[j] = p.Threads[ti].Ops[j+1]
+	}
+	p.Threads[ti].Ops[n-1] = Op{}
+	return p
+}
+
+// tryOpEdit applies edit to a copy of p.Threads[ti].Ops[oi], keeping
+// the edit (and updating p in place) if the result still
+// demonstrates the counterexample.
+func tryOpEdit(p *Prog, ti, oi int, edit func(*Op), cache *evalCache) bool {
+	cand := *p
+	edit(&cand.Threads[ti].Ops[oi])
+	if !cache.differ(cand) {
+		return false
+	}
+	*p = cand
+	return true
+}
+
+// shrinkInt proposes a smaller candidate for an integer value or
+// address used in a litmus test, for delta-debugging purposes.
+func shrinkInt(v int) (int, bool) {
+	if v <= 0 {
+		return 0, false
+	}
+	return v - 1, true
+}
+
+// evalCache memoizes Eval results per candidate program for a fixed
+// pair of models, since the minimization loop repeatedly re-checks
+// small variations of the same program.
+type evalCache struct {
+	weak, strong Model
+	results      map[string]bool
+}
+
+func newEvalCache(weak, strong Model) *evalCache {
+	return &evalCache{weak: weak, strong: strong, results: make(map[string]bool)}
+}
+
+// differ reports whether weak permits an outcome on p that strong
+// forbids.
+func (c *evalCache) differ(p Prog) bool {
+	key := p.String()
+	if v, ok := c.results[key]; ok {
+		return v
+	}
+
+	var weakOutcomes, strongOutcomes OutcomeSet
+	c.weak.Eval(&p, &weakOutcomes)
+	c.strong.Eval(&p, &strongOutcomes)
+	v := weakOutcomes != strongOutcomes && weakOutcomes.Contains(&strongOutcomes)
+	c.results[key] = v
+	return v
+}
+
+// witnessOutcome describes an outcome permitted by weak but
+// forbidden by strong on p, for use in diagnostic output. It panics
+// if weak does not actually differ from strong on p.
+func witnessOutcome(p Prog, weak, strong Model) string {
+	var weakOutcomes, strongOutcomes OutcomeSet
+	weak.Eval(&p, &weakOutcomes)
+	strong.Eval(&p, &strongOutcomes)
+	for _, o := range weakOutcomes.Outcomes() {
+		if !strongOutcomes.ContainsOutcome(o) {
+			return o.String(&p)
+		}
+	}
+	panic("witnessOutcome: models do not differ on p")
+}
@@ -0,0 +1,30 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+// TestMinimizeCounterexample confirms that minimizeCounterexample
+// drops an irrelevant thread from a counterexample while keeping the
+// program a valid counterexample for the same pair of models.
+func TestMinimizeCounterexample(t *testing.T) {
+	p := mp()
+	// T2's load doesn't affect whether RC and TSO disagree on mp's
+	// outcome, so minimizeCounterexample should remove it.
+	p.Threads[2].Ops[0] = Op{Type: OpLoad, Addr: 0}
+
+	cache := newEvalCache(RCModel{}, TSOModel{})
+	if !cache.differ(p) {
+		t.Fatalf("test setup broken: RC and TSO don't differ on p")
+	}
+
+	got := minimizeCounterexample(p, RCModel{}, TSOModel{})
+	if want := mp(); got != want {
+		t.Errorf("minimizeCounterexample(p) = %+v, want %+v", got, want)
+	}
+	if !cache.differ(got) {
+		t.Errorf("minimizeCounterexample(p) no longer demonstrates the counterexample")
+	}
+}
@@ -0,0 +1,510 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxThreads and MaxOps bound the size of the litmus test programs
+// GenerateProgs produces. A Prog's Threads and a Thread's Ops are
+// fixed-size arrays rather than slices so that the zero Prog is
+// itself a valid (empty) program: an unused thread or op slot has
+// Type OpExit, which main.go uses as its "no program here" sentinel.
+const (
+	MaxThreads = 4
+	MaxOps     = 2
+)
+
+// OpType is the kind of a single memory operation in a litmus test.
+type OpType int
+
+const (
+	// OpExit marks an unused Op or Thread slot; it is the zero
+	// value so a zero Prog is an empty program.
+	OpExit OpType = iota
+	OpLoad
+	OpStore
+	// OpAcquireLoad and OpReleaseStore are a load/store pair with
+	// acquire/release semantics: an OpAcquireLoad that reads the
+	// value written by an OpReleaseStore synchronizes with it, so
+	// everything program-ordered before the release is visible to
+	// everything program-ordered after the acquire.
+	OpAcquireLoad
+	OpReleaseStore
+	// OpFence is a full two-way barrier in the simulated models
+	// (see simulate): it cannot issue until the issuing thread's
+	// store buffer has drained, so no memory operation before it in
+	// program order is reordered with one after it. PowerModel
+	// gives it no effect (see PowerModel).
+	OpFence
+)
+
+func (t OpType) String() string {
+	switch t {
+	case OpExit:
+		return "exit"
+	case OpLoad:
+		return "ld"
+	case OpStore:
+		return "st"
+	case OpAcquireLoad:
+		return "ld-acq"
+	case OpReleaseStore:
+		return "st-rel"
+	case OpFence:
+		return "fence"
+	default:
+		return "?"
+	}
+}
+
+// isLoad and isStore classify an OpType for the models below.
+func (t OpType) isLoad() bool  { return t == OpLoad || t == OpAcquireLoad }
+func (t OpType) isStore() bool { return t == OpStore || t == OpReleaseStore }
+
+// Op is a single memory operation: a load or store of Addr (and,
+// for stores, Value), a fence, or OpExit.
+type Op struct {
+	Type  OpType
+	Addr  int
+	Value int
+}
+
+func (o Op) String() string {
+	switch {
+	case o.Type == OpExit:
+		return "exit"
+	case o.Type == OpFence:
+		return "fence"
+	case o.Type.isLoad():
+		return fmt.Sprintf("%s x%d", o.Type, o.Addr)
+	default:
+		return fmt.Sprintf("%s x%d = %d", o.Type, o.Addr, o.Value)
+	}
+}
+
+// Thread is one thread's sequence of Ops, padded with OpExit.
+type Thread struct {
+	Ops [MaxOps]Op
+}
+
+// numOps returns the number of real (non-OpExit) ops at the start of
+// t.Ops.
+func (t *Thread) numOps() int {
+	for i := range t.Ops {
+		if t.Ops[i].Type == OpExit {
+			return i
+		}
+	}
+	return MaxOps
+}
+
+// Prog is a litmus test: a fixed number of threads, each with a
+// fixed number of ops, unused slots padded with OpExit.
+type Prog struct {
+	Threads [MaxThreads]Thread
+}
+
+// numThreads returns the number of real (non-empty) threads at the
+// start of p.Threads.
+func (p *Prog) numThreads() int {
+	for i := range p.Threads {
+		if p.Threads[i].Ops[0].Type == OpExit {
+			return i
+		}
+	}
+	return MaxThreads
+}
+
+func (p *Prog) String() string {
+	var sb strings.Builder
+	for i := 0; i < p.numThreads(); i++ {
+		fmt.Fprintf(&sb, "T%d:\n", i)
+		t := &p.Threads[i]
+		for j := 0; j < t.numOps(); j++ {
+			fmt.Fprintf(&sb, "  %s\n", t.Ops[j])
+		}
+	}
+	return sb.String()
+}
+
+// plainOps, syncOps, and numAddrs bound the litmus tests
+// GenerateProgs produces: each op reads or writes one of numAddrs
+// addresses, and every store writes the value 1 (the only
+// interesting value to a location initialized to 0). plainOps is the
+// original load/store-only vocabulary, used for the full MaxThreads
+// sweep that finds reordering counterexamples like MP and IRIW.
+// syncOps adds the acquire/release/fence operations that distinguish
+// the weak models from TSO; those only need two threads to set up a
+// synchronizes-with edge, and len(seqs)^MaxThreads blows up too fast
+// to sweep the full vocabulary at MaxThreads threads (147M programs,
+// multiple days, instead of seconds), so syncThreads caps how many
+// threads of a program may draw from it.
+var plainOps = []OpType{OpLoad, OpStore}
+var syncOps = []OpType{OpLoad, OpStore, OpAcquireLoad, OpReleaseStore, OpFence}
+
+const numAddrs = 2
+const syncThreads = 2
+
+// GenerateProgs generates every litmus test program with up to
+// MaxThreads threads of up to MaxOps plain ops each, plus every
+// program with up to syncThreads threads of up to MaxOps ops drawn
+// from the full synchronizing vocabulary.
+func GenerateProgs() <-chan Prog {
+	ch := make(chan Prog)
+	go func() {
+		defer close(ch)
+		var p Prog
+		genProgs(&p, 0, MaxThreads, genThreadSeqs(plainOps), ch)
+		p = Prog{}
+		genProgs(&p, 0, syncThreads, genThreadSeqs(syncOps), ch)
+	}()
+	return ch
+}
+
+// genThreadSeqs enumerates every non-empty sequence of up to MaxOps
+// ops, drawn from vocab and numAddrs addresses, a single thread can
+// perform.
+func genThreadSeqs(vocab []OpType) []Thread {
+	var seqs []Thread
+	var ops [MaxOps]Op
+	var build func(n int)
+	build = func(n int) {
+		if n > 0 {
+			var t Thread
+			copy(t.Ops[:], ops[:n])
+			seqs = append(seqs, t)
+		}
+		if n == MaxOps {
+			return
+		}
+		for _, typ := range vocab {
+			for addr := 0; addr < numAddrs; addr++ {
+				op := Op{Type: typ, Addr: addr}
+				if typ.isStore() {
+					op.Value = 1
+				}
+				ops[n] = op
+				build(n + 1)
+			}
+		}
+	}
+	build(0)
+	return seqs
+}
+
+// genProgs emits, for every ti, the program with threads 0..ti-1 set
+// to p.Threads and the rest left empty, then recurses to try every
+// sequence in seqs for thread ti. This yields every program with 0
+// to maxThreads active threads built from seqs.
+func genProgs(p *Prog, ti, maxThreads int, seqs []Thread, ch chan<- Prog) {
+	ch <- *p
+	if ti == maxThreads {
+		return
+	}
+	for _, t := range seqs {
+		p.Threads[ti] = t
+		genProgs(p, ti+1, maxThreads, seqs, ch)
+	}
+	p.Threads[ti] = Thread{}
+}
+
+// opRef identifies one Op by its position in a Prog.
+type opRef struct {
+	thread, idx int
+}
+
+func (p *Prog) op(r opRef) *Op { return &p.Threads[r.thread].Ops[r.idx] }
+
+// writesByAddr groups the refs of every store (OpStore or
+// OpReleaseStore) in p by address.
+func (p *Prog) writesByAddr() map[int][]opRef {
+	byAddr := map[int][]opRef{}
+	for ti := 0; ti < p.numThreads(); ti++ {
+		t := &p.Threads[ti]
+		for oi := 0; oi < t.numOps(); oi++ {
+			if t.Ops[oi].Type.isStore() {
+				byAddr[t.Ops[oi].Addr] = append(byAddr[t.Ops[oi].Addr], opRef{ti, oi})
+			}
+		}
+	}
+	return byAddr
+}
+
+// CoherenceOrder is one total order on the writes to each address in
+// a Prog, used by an axiomatic model to decide which write a load
+// may observe.
+type CoherenceOrder struct {
+	prog  *Prog
+	order map[int][]opRef // address -> writes to that address, in coherence order
+}
+
+// CoherenceOrders enumerates every total order on the writes to each
+// address in p.
+func (p *Prog) CoherenceOrders() []CoherenceOrder {
+	byAddr := p.writesByAddr()
+	var addrs []int
+	for a := range byAddr {
+		addrs = append(addrs, a)
+	}
+	sort.Ints(addrs)
+
+	orders := []CoherenceOrder{{prog: p, order: map[int][]opRef{}}}
+	for _, a := range addrs {
+		var next []CoherenceOrder
+		for _, perm := range permutations(byAddr[a]) {
+			for _, o := range orders {
+				cp := CoherenceOrder{prog: p, order: make(map[int][]opRef, len(o.order)+1)}
+				for k, v := range o.order {
+					cp.order[k] = v
+				}
+				cp.order[a] = perm
+				next = append(next, cp)
+			}
+		}
+		orders = next
+	}
+	return orders
+}
+
+// permutations returns every ordering of refs.
+func permutations(refs []opRef) [][]opRef {
+	if len(refs) <= 1 {
+		return [][]opRef{append([]opRef{}, refs...)}
+	}
+	var out [][]opRef
+	for i := range refs {
+		rest := make([]opRef, 0, len(refs)-1)
+		rest = append(rest, refs[:i]...)
+		rest = append(rest, refs[i+1:]...)
+		for _, p := range permutations(rest) {
+			out = append(out, append([]opRef{refs[i]}, p...))
+		}
+	}
+	return out
+}
+
+// writes returns the writes to addr in coherence order.
+func (c CoherenceOrder) writes(addr int) []opRef {
+	return c.order[addr]
+}
+
+// Before reports whether the write at w precedes the load at l: they
+// are on the same thread with w earlier in program order, or w's
+// address has no later write that is also program-ordered before l
+// on l's own thread. It is used by the weak models (see weak.go) as
+// their default same-thread/coherence visibility rule; cross-thread
+// visibility beyond that is governed by fences and acquire/release,
+// which evalWeak checks separately.
+func (c CoherenceOrder) Before(w, l opRef) bool {
+	if w.thread == l.thread {
+		return w.idx < l.idx
+	}
+	order := c.writes(c.prog.op(w).Addr)
+	wi := indexOf(order, w)
+	for _, w2 := range order[wi+1:] {
+		if w2.thread == l.thread && w2.idx < l.idx {
+			// The load's own thread has already issued a
+			// later write to this address, so it cannot
+			// observe the earlier one w.
+			return false
+		}
+	}
+	return true
+}
+
+func indexOf(refs []opRef, r opRef) int {
+	for i, x := range refs {
+		if x == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadRefs returns the refs of every load in p, in thread-major
+// program order; their position in this slice is the bit index
+// Outcome uses for that load.
+func (p *Prog) loadRefs() []opRef {
+	var refs []opRef
+	for ti := 0; ti < p.numThreads(); ti++ {
+		t := &p.Threads[ti]
+		for oi := 0; oi < t.numOps(); oi++ {
+			if t.Ops[oi].Type.isLoad() {
+				refs = append(refs, opRef{ti, oi})
+			}
+		}
+	}
+	return refs
+}
+
+// Outcome records whether each load in a program observed the
+// stored value (1) rather than the initial value (0), as a bitmask
+// indexed by the load's position among loadRefs. MaxThreads*MaxOps
+// bounds the number of loads to 8 so two OutcomeSets remain
+// comparable with ==.
+type Outcome uint8
+
+// OutcomeSet is the set of Outcomes a model permits for a program,
+// represented as a bitset over all possible Outcome values.
+type OutcomeSet [4]uint64
+
+// Add records that o is a permitted outcome.
+func (s *OutcomeSet) Add(o Outcome) {
+	s[o/64] |= 1 << (uint(o) % 64)
+}
+
+// Contains reports whether every outcome permitted by o is also
+// permitted by s.
+func (s *OutcomeSet) Contains(o *OutcomeSet) bool {
+	for i := range s {
+		if o[i]&^s[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Outcomes returns the individual Outcomes in s.
+func (s *OutcomeSet) Outcomes() []Outcome {
+	var out []Outcome
+	for i := 0; i < 256; i++ {
+		if s[i/64]&(1<<(uint(i)%64)) != 0 {
+			out = append(out, Outcome(i))
+		}
+	}
+	return out
+}
+
+// ContainsOutcome reports whether s permits o.
+func (s *OutcomeSet) ContainsOutcome(o Outcome) bool {
+	return s[o/64]&(1<<(uint(o)%64)) != 0
+}
+
+// String renders o as the value each load in p observed, e.g.
+// "ld x0=1, ld-acq x1=0".
+func (o Outcome) String(p *Prog) string {
+	refs := p.loadRefs()
+	var parts []string
+	for i, r := range refs {
+		v := 0
+		if o&(1<<uint(i)) != 0 {
+			v = 1
+		}
+		op := p.op(r)
+		parts = append(parts, fmt.Sprintf("%s x%d=%d", op.Type, op.Addr, v))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CandidateOutcomes enumerates the outcomes of p that are consistent
+// with coherence order c and canObserve, which reports whether a
+// given load may observe a given write. For each load, in program
+// order, the load may always observe the initial value (0) unless
+// it is preceded in its own thread by a store to the same address
+// (the standard "a thread sees its own writes" rule), or it may
+// observe any write w for which canObserve(load, w, c.Before(w,
+// load)) is true.
+func (p *Prog) CandidateOutcomes(c CoherenceOrder, canObserve func(load, write opRef, ordered bool) bool) []Outcome {
+	refs := p.loadRefs()
+
+	var results []Outcome
+	var rec func(i int, out Outcome)
+	rec = func(i int, out Outcome) {
+		if i == len(refs) {
+			results = append(results, out)
+			return
+		}
+		l := refs[i]
+		load := p.op(l)
+
+		// A thread always reads back its own most recent store
+		// to the same address, if any.
+		if own, ok := p.ownWrite(l); ok {
+			bit := Outcome(0)
+			if p.op(own).Value != 0 {
+				bit = 1
+			}
+			rec(i+1, out|(bit<<uint(i)))
+			return
+		}
+
+		// Otherwise, it may read the initial value, or any
+		// write canObserve permits.
+		rec(i+1, out)
+		for _, w := range c.writes(load.Addr) {
+			if w.thread == l.thread {
+				continue
+			}
+			if canObserve(l, w, c.Before(w, l)) {
+				bit := Outcome(0)
+				if p.op(w).Value != 0 {
+					bit = 1
+				}
+				rec(i+1, out|(bit<<uint(i)))
+			}
+		}
+	}
+	rec(0, 0)
+
+	return dedupeOutcomes(results)
+}
+
+// synchronizes reports whether the store at w is guaranteed to be
+// visible to the load at l because both sides executed a barrier:
+// w's thread performs a fence or release store at or after w, and
+// l's thread performs a fence or acquire load at or before l. This
+// is a static approximation of synchronizes-with: it does not check
+// that any guard load actually observed the value that established
+// the ordering, only that the right kind of operations are present.
+func (p *Prog) synchronizes(w, l opRef) bool {
+	wt := &p.Threads[w.thread]
+	storeBarriered := false
+	for i := w.idx; i < wt.numOps(); i++ {
+		if wt.Ops[i].Type == OpFence || wt.Ops[i].Type == OpReleaseStore {
+			storeBarriered = true
+			break
+		}
+	}
+	if !storeBarriered {
+		return false
+	}
+
+	lt := &p.Threads[l.thread]
+	for i := 0; i <= l.idx; i++ {
+		if lt.Ops[i].Type == OpFence || lt.Ops[i].Type == OpAcquireLoad {
+			return true
+		}
+	}
+	return false
+}
+
+// ownWrite returns the most recent store to l's address earlier in
+// l's own thread, if any.
+func (p *Prog) ownWrite(l opRef) (opRef, bool) {
+	t := &p.Threads[l.thread]
+	addr := t.Ops[l.idx].Addr
+	for i := l.idx - 1; i >= 0; i-- {
+		if t.Ops[i].Type.isStore() && t.Ops[i].Addr == addr {
+			return opRef{l.thread, i}, true
+		}
+	}
+	return opRef{}, false
+}
+
+func dedupeOutcomes(outcomes []Outcome) []Outcome {
+	seen := map[Outcome]bool{}
+	var out []Outcome
+	for _, o := range outcomes {
+		if !seen[o] {
+			seen[o] = true
+			out = append(out, o)
+		}
+	}
+	return out
+}
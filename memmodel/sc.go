@@ -0,0 +1,17 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// SCModel is sequential consistency: every operation, across every
+// thread, happens in some single total order consistent with each
+// thread's own program order, and a load returns the value of the
+// most recent store to the same address in that order.
+type SCModel struct{}
+
+func (m SCModel) String() string { return "SC" }
+
+func (m SCModel) Eval(p *Prog, outcomes *OutcomeSet) {
+	simulate(p, storeImmediate, outcomes)
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// storeMode selects how simulate drains a thread's stores into
+// shared memory.
+type storeMode int
+
+const (
+	// storeImmediate commits every store the instant it issues, so
+	// no thread ever has a pending buffer: this is ordinary
+	// sequential consistency, and is also what TSOModel{StoreMFence:
+	// true} wants, since a fence after every store has the same
+	// effect.
+	storeImmediate storeMode = iota
+	// storeFIFO buffers each thread's stores and drains them one at
+	// a time, oldest first: a thread's own writes always become
+	// visible to others in its own program order (TSO).
+	storeFIFO
+	// storeReorder also buffers each thread's stores, but lets them
+	// drain in any order, so a thread's plain writes may become
+	// visible to others out of its own program order — except for
+	// an OpReleaseStore, which (like OpFence) cannot issue until the
+	// buffer has drained, and then commits directly, so everything
+	// program-ordered before a release is visible before it is.
+	storeReorder
+)
+
+// simBuf is a thread's pending store buffer: a bounded queue (at most
+// MaxOps entries can ever be outstanding) kept as a fixed-size array
+// so simState stays comparable and usable as a map key.
+type simBuf struct {
+	ops [MaxOps]Op
+	n   int
+}
+
+func (b simBuf) at(i int) Op { return b.ops[i] }
+
+func (b simBuf) without(i int) simBuf {
+	var out simBuf
+	out.n = b.n - 1
+	for j, k := 0, 0; j < b.n; j++ {
+		if j == i {
+			continue
+		}
+		out.ops[k] = b.ops[j]
+		k++
+	}
+	return out
+}
+
+func (b simBuf) appended(op Op) simBuf {
+	out := b
+	out.ops[out.n] = op
+	out.n++
+	return out
+}
+
+// simState is everything about a point in a simulated execution that
+// determines how it can continue: each thread's program counter and
+// pending store buffer, and the current contents of shared memory
+// (indexed directly by address, since numAddrs is small and fixed).
+// It holds no record of what any load has observed so far, which is
+// what lets simulate memoize on it (see below).
+type simState struct {
+	pc  [MaxThreads]int
+	buf [MaxThreads]simBuf
+	mem [numAddrs]int
+}
+
+// simulate explores every interleaving of p's threads under an
+// operational semantics selected by mode and sets outcomes to the
+// resulting outcomes, discarding whatever it held before. A load reads its own thread's most recently
+// buffered (undrained) store to that address if there is one, else
+// the current value in shared memory. OpFence drains the issuing
+// thread's entire buffer before anything else on that thread may
+// happen.
+//
+// Because every load reads from a single shared memory (plus at most
+// its own thread's buffer), this operational model is multi-copy-
+// atomic under every mode: every thread agrees on the relative order
+// in which other threads' writes became visible, so it can never
+// produce the IRIW-style disagreement evalWeak's axiomatic, per-
+// address model permits (see weak.go). storeReorder still disagrees
+// with storeFIFO on message passing: it may let a thread's second
+// write become visible before its first.
+//
+// Many interleavings pass through the same simState — e.g. any
+// schedule that runs two independent threads' stores in a different
+// order reaches the same shared memory either way — so simulate
+// memoizes the set of outcome bitmasks each state's continuations can
+// still contribute, indexed only by simState, not by what has been
+// observed so far. That turns what would otherwise be exponential
+// blowup in the number of interleavings into one evaluation per
+// distinct reachable state.
+func simulate(p *Prog, mode storeMode, outcomes *OutcomeSet) {
+	*outcomes = OutcomeSet{}
+	refs := p.loadRefs()
+	bitIndex := make(map[opRef]int, len(refs))
+	for i, r := range refs {
+		bitIndex[r] = i
+	}
+	nThreads := p.numThreads()
+
+	cache := make(map[simState][]Outcome)
+
+	// explore returns every bitmask that the continuations of s
+	// might still OR into the final outcome, independent of
+	// whatever has already been decided before s.
+	var explore func(s simState) []Outcome
+	explore = func(s simState) []Outcome {
+		if masks, ok := cache[s]; ok {
+			return masks
+		}
+
+		var masks []Outcome
+		progress := false
+
+		for ti := 0; ti < nThreads; ti++ {
+			if s.pc[ti] >= p.Threads[ti].numOps() {
+				continue
+			}
+			op := p.Threads[ti].Ops[s.pc[ti]]
+			mustDrainFirst := op.Type == OpFence || (op.Type == OpReleaseStore && mode == storeReorder)
+			if mustDrainFirst && s.buf[ti].n > 0 {
+				continue
+			}
+
+			progress = true
+			next := s
+			next.pc[ti]++
+
+			switch {
+			case op.Type.isLoad():
+				val, ok := lastBuffered(s.buf[ti], op.Addr)
+				if !ok {
+					val = s.mem[op.Addr]
+				}
+				var bit Outcome
+				if val != 0 {
+					bit = 1 << uint(bitIndex[opRef{ti, s.pc[ti]}])
+				}
+				for _, m := range explore(next) {
+					masks = append(masks, bit|m)
+				}
+			case op.Type.isStore():
+				if mode == storeImmediate || (mode == storeReorder && op.Type == OpReleaseStore) {
+					next.mem[op.Addr] = op.Value
+				} else {
+					next.buf[ti] = s.buf[ti].appended(op)
+				}
+				masks = append(masks, explore(next)...)
+			default: // OpFence
+				masks = append(masks, explore(next)...)
+			}
+		}
+
+		if mode != storeImmediate {
+			for ti := 0; ti < nThreads; ti++ {
+				limit := s.buf[ti].n
+				if mode == storeFIFO && limit > 1 {
+					limit = 1 // only the oldest entry may drain
+				}
+				for bi := 0; bi < limit; bi++ {
+					progress = true
+					next := s
+					drained := s.buf[ti].at(bi)
+					next.buf[ti] = s.buf[ti].without(bi)
+					next.mem[drained.Addr] = drained.Value
+					masks = append(masks, explore(next)...)
+				}
+			}
+		}
+
+		if !progress {
+			masks = []Outcome{0}
+		}
+		masks = dedupeOutcomes(masks)
+		cache[s] = masks
+		return masks
+	}
+
+	for _, m := range explore(simState{}) {
+		outcomes.Add(m)
+	}
+}
+
+// lastBuffered returns the value of the most recently buffered store
+// to addr in buf, if any.
+func lastBuffered(buf simBuf, addr int) (int, bool) {
+	for i := buf.n - 1; i >= 0; i-- {
+		if buf.ops[i].Addr == addr {
+			return buf.ops[i].Value, true
+		}
+	}
+	return 0, false
+}
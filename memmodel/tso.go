@@ -0,0 +1,34 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// TSOModel is total store order: each thread's stores drain into
+// shared memory through a FIFO buffer, so a later load by the same
+// thread may observe a store that has not yet become visible to
+// other threads, and a store followed by a load of a different
+// address may appear, to other threads, to have happened after that
+// load. It is otherwise multi-copy-atomic: every thread sees stores
+// from every other thread in the same order.
+type TSOModel struct {
+	// StoreMFence simulates inserting a full memory fence after
+	// every store, which drains it immediately instead of
+	// buffering it — the same behavior as SCModel.
+	StoreMFence bool
+}
+
+func (m TSOModel) String() string {
+	if m.StoreMFence {
+		return "TSO+mfence"
+	}
+	return "TSO"
+}
+
+func (m TSOModel) Eval(p *Prog, outcomes *OutcomeSet) {
+	mode := storeFIFO
+	if m.StoreMFence {
+		mode = storeImmediate
+	}
+	simulate(p, mode, outcomes)
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// RCModel is a release-consistency memory model in the style of
+// ARMv8: like TSOModel, every thread reads from one shared memory, so
+// every thread agrees on the relative order in which other threads'
+// writes became visible (it is "other-multi-copy-atomic", forbidding
+// IRIW), but unlike TSOModel's FIFO store buffer, a thread's plain
+// writes may drain into memory out of its own program order,
+// permitting MP. An OpReleaseStore (like OpFence) drains the issuing
+// thread's buffer before it commits, so everything program-ordered
+// before a release is visible no later than the release itself. See
+// simulate's storeReorder mode.
+type RCModel struct{}
+
+// PowerModel is a weak memory model in the style of POWER: plain
+// loads and stores may be freely reordered and may each
+// independently observe any remote write, even when doing so would
+// require two different threads to disagree about the relative order
+// of two other threads' writes (the IRIW anomaly) — unlike RCModel,
+// it is not multi-copy-atomic. Barrier ops place no constraint on
+// its outcomes: every load may still read the initial value or any
+// other thread's write to its address, so OpAcquireLoad and
+// OpReleaseStore behave as a plain load and store and OpFence has no
+// effect. See evalWeak.
+type PowerModel struct{}
+
+func (m RCModel) String() string { return "RC" }
+
+func (m PowerModel) String() string { return "Power" }
+
+func (m RCModel) Eval(p *Prog, outcomes *OutcomeSet) {
+	simulate(p, storeReorder, outcomes)
+}
+
+func (m PowerModel) Eval(p *Prog, outcomes *OutcomeSet) {
+	evalWeak(p, outcomes)
+}
+
+// evalWeak sets outcomes to all candidate outcomes of p under a weak,
+// axiomatic, non-multi-copy-atomic memory model: for each coherence
+// order, CandidateOutcomes asks, for every load and every write to
+// the same address, whether the load may observe that write. A load
+// may observe any write that synchronizes reports it as ordered
+// before (a static check for barrier ops on both sides) or that
+// is simply coherence-ordered before it (c.Before rules out only a
+// write superseded, on the load's own thread, by a later same-address
+// write). Because every load's candidates are decided independently
+// of every other load's, nothing here stops two different loads on
+// two different threads from disagreeing about which of two other
+// threads' writes happened first, which is what lets PowerModel
+// permit IRIW.
+func evalWeak(p *Prog, outcomes *OutcomeSet) {
+	*outcomes = OutcomeSet{}
+	for _, order := range p.CoherenceOrders() {
+		for _, outcome := range p.CandidateOutcomes(order, func(load, write opRef, coherenceBefore bool) bool {
+			return coherenceBefore || p.synchronizes(write, load)
+		}) {
+			outcomes.Add(outcome)
+		}
+	}
+}